@@ -0,0 +1,537 @@
+// Copyright Project Contour Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/projectcontour/contour/apis/projectcontour/v1"
+	contour_v1alpha1 "github.com/projectcontour/contour/apis/projectcontour/v1alpha1"
+	"github.com/projectcontour/contour/internal/timeout"
+	"github.com/sirupsen/logrus"
+	networking_v1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	annotationResponseTimeout = "projectcontour.io/response-timeout"
+	annotationRequestTimeout  = "projectcontour.io/request-timeout"
+
+	annotationGlobalRateLimitRequestsPerUnit = "projectcontour.io/global-rate-limit-requests-per-unit"
+	annotationGlobalRateLimitUnit            = "projectcontour.io/global-rate-limit-unit"
+	annotationGlobalRateLimitDescriptorKey   = "projectcontour.io/global-rate-limit-descriptor-key"
+
+	descriptorKeyRemoteAddress = "remote_address"
+	descriptorKeyPath          = "path"
+	descriptorKeyHeaderPrefix  = "header:"
+
+	annotationMaxConnections     = "projectcontour.io/max-connections"
+	annotationMaxPendingRequests = "projectcontour.io/max-pending-requests"
+	annotationMaxRequests        = "projectcontour.io/max-requests"
+	annotationMaxRetries         = "projectcontour.io/max-retries"
+
+	annotationConnectTimeout        = "projectcontour.io/connect-timeout"
+	annotationIdleTimeout           = "projectcontour.io/idle-timeout"
+	annotationIdleConnectionTimeout = "projectcontour.io/idle-connection-timeout"
+
+	annotationLocalRateLimitRequests           = "projectcontour.io/local-rate-limit-requests"
+	annotationLocalRateLimitUnit               = "projectcontour.io/local-rate-limit-unit"
+	annotationLocalRateLimitBurst              = "projectcontour.io/local-rate-limit-burst"
+	annotationLocalRateLimitResponseStatusCode = "projectcontour.io/local-rate-limit-response-status-code"
+)
+
+// IngressProcessor translates Ingresses into DAG
+// objects and adds them to the DAG.
+type IngressProcessor struct {
+	logrus.FieldLogger
+
+	dag    *DAG
+	source *KubernetesCache
+
+	// ClientCertificate is the optional identifier of the TLS secret containing client certificate and
+	// private key to be used when establishing TLS connection to upstream cluster.
+	ClientCertificate *types.NamespacedName
+
+	// EnableExternalNameService enables support for
+	// ExternalNameServices.
+	//
+	// Defaults to disabled for security reasons.
+	EnableExternalNameService bool
+
+	// RequestHeadersPolicy defines how headers are managed during forwarding.
+	RequestHeadersPolicy *HeadersPolicy
+
+	// ResponseHeadersPolicy defines how headers are managed during forwarding.
+	ResponseHeadersPolicy *HeadersPolicy
+
+	// ResponseTimeout sets the timeout for receiving a response from the
+	// server after processing a request from client. If not supplied the
+	// timeout duration is undefined.
+	ResponseTimeout timeout.Setting
+
+	// ConnectTimeout defines how long the proxy should wait when establishing
+	// connection to upstream service.
+	ConnectTimeout time.Duration
+
+	// MaxRequestsPerConnection defines the maximum number of requests per
+	// connection to the upstream before it is closed.
+	MaxRequestsPerConnection *uint32
+
+	// PerConnectionBufferLimitBytes defines the soft limit on size of the
+	// cluster's new connection read and write buffers.
+	PerConnectionBufferLimitBytes *uint32
+
+	// SetSourceMetadataOnRoutes defines whether to set the Kind/Namespace/Name
+	// fields on generated Routes.
+	SetSourceMetadataOnRoutes bool
+
+	// GlobalCircuitBreakerDefaults defines global circuit breaker defaults.
+	GlobalCircuitBreakerDefaults *contour_v1alpha1.CircuitBreakers
+
+	// GlobalRateLimitService defines Envoy's Global RateLimit Service
+	// configuration.
+	GlobalRateLimitService *contour_v1alpha1.RateLimitServiceConfig
+
+	// UpstreamTLS defines the TLS settings like min/max version
+	// and cipher suites for upstream connections.
+	UpstreamTLS *UpstreamTLS
+}
+
+// Run translates Ingresses into DAG objects and adds them to the DAG.
+func (p *IngressProcessor) Run(dag *DAG, source *KubernetesCache) {
+	p.dag = dag
+	p.source = source
+
+	// reset the processor when we're done
+	defer func() {
+		p.dag = nil
+		p.source = nil
+	}()
+
+	for _, ing := range p.source.ingresses {
+		p.computeBackendsForIngress(ing)
+	}
+}
+
+// computeBackendsForIngress adds the routes in ing to the DAG.
+func (p *IngressProcessor) computeBackendsForIngress(ing *networking_v1.Ingress) {
+	log := p.WithField("ingress", ing.Name).WithField("namespace", ing.Namespace)
+
+	for _, rule := range ing.Spec.Rules {
+		host := rule.Host
+		for _, httppath := range httppaths(rule) {
+			if httppath.Backend.Service == nil {
+				continue
+			}
+
+			path := stringOrDefault(httppath.Path, "/")
+
+			service, err := p.dagServiceFromBackend(ing.Namespace, httppath.Backend.Service)
+			if err != nil {
+				log.WithError(err).Error("failed to look up service for ingress backend")
+				continue
+			}
+
+			r, err := p.route(ing, host, path, httppath.PathType, service, nil, httppath.Backend.Service.Name, servicePort(httppath.Backend.Service), log)
+			if err != nil {
+				log.WithError(err).Error("failed to build route for ingress backend")
+				continue
+			}
+
+			p.dag.AddRoute(r)
+		}
+	}
+}
+
+// httppaths returns a slice of HTTPIngressPath values for a given set of
+// IngressRules. In the case that the IngressRule contains no valid
+// HTTPIngressPaths, a nil slice is returned.
+func httppaths(rule networking_v1.IngressRule) []networking_v1.HTTPIngressPath {
+	if rule.HTTP == nil {
+		// rule.HTTP value is optional.
+		return nil
+	}
+	return rule.HTTP.Paths
+}
+
+// ingressTimeoutPolicy builds a RouteTimeoutPolicy from the response-timeout
+// (and its deprecated request-timeout alias), idle-timeout and
+// idle-connection-timeout annotations on ing. An absent or invalid
+// response timeout falls back to the processor's configured
+// ResponseTimeout; an absent or invalid idle timeout is left as the zero
+// value Setting.
+func (p *IngressProcessor) ingressTimeoutPolicy(ing *networking_v1.Ingress, log logrus.FieldLogger) RouteTimeoutPolicy {
+	response := p.ResponseTimeout
+
+	val, ok := ing.Annotations[annotationResponseTimeout]
+	if !ok {
+		// request-timeout is a deprecated alias for response-timeout.
+		val, ok = ing.Annotations[annotationRequestTimeout]
+	}
+
+	if ok {
+		setting, err := timeout.Parse(val)
+		if err != nil {
+			log.WithError(err).Errorf("error parsing %q annotation", annotationResponseTimeout)
+		} else {
+			response = setting
+		}
+	}
+
+	return RouteTimeoutPolicy{
+		ResponseTimeout:       response,
+		IdleTimeout:           parseTimeoutAnnotation(ing, annotationIdleTimeout, log),
+		IdleConnectionTimeout: parseTimeoutAnnotation(ing, annotationIdleConnectionTimeout, log),
+	}
+}
+
+// parseTimeoutAnnotation returns the timeout.Setting parsed from the named
+// annotation on ing, or the zero value Setting if the annotation is absent
+// or fails to parse.
+func parseTimeoutAnnotation(ing *networking_v1.Ingress, name string, log logrus.FieldLogger) timeout.Setting {
+	val, ok := ing.Annotations[name]
+	if !ok {
+		return timeout.Setting{}
+	}
+
+	setting, err := timeout.Parse(val)
+	if err != nil {
+		log.WithError(err).Errorf("error parsing %q annotation", name)
+		return timeout.Setting{}
+	}
+	return setting
+}
+
+// ingressConnectTimeout returns the connect timeout for an Ingress backend,
+// parsed from the connect-timeout annotation on ing, falling back to def if
+// the annotation is absent or invalid. Unlike the idle timeout annotations,
+// "infinity" is not a valid value here: Envoy's cluster connect_timeout
+// cannot be disabled.
+func (p *IngressProcessor) ingressConnectTimeout(ing *networking_v1.Ingress, def time.Duration, log logrus.FieldLogger) time.Duration {
+	val, ok := ing.Annotations[annotationConnectTimeout]
+	if !ok {
+		return def
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		log.WithError(err).Errorf("error parsing %q annotation", annotationConnectTimeout)
+		return def
+	}
+	return d
+}
+
+// route builds a DAG Route for a single Ingress backend.
+func (p *IngressProcessor) route(ingress *networking_v1.Ingress, host, path string, pathType networking_v1.PathType, service *Service, clientCertSecret *Secret, serviceName string, servicePort int32, log logrus.FieldLogger) (*Route, error) {
+	r := &Route{
+		HostName:           host,
+		PathMatchCondition: pathMatchCondition(path, pathType),
+		TimeoutPolicy:      p.ingressTimeoutPolicy(ingress, log),
+		Clusters: []*Cluster{{
+			Upstream:                      service,
+			Protocol:                      service.Protocol,
+			RequestHeadersPolicy:          p.RequestHeadersPolicy,
+			ResponseHeadersPolicy:         p.ResponseHeadersPolicy,
+			ClientCertificate:             p.ClientCertificate,
+			TimeoutPolicy:                 ClusterTimeoutPolicy{ConnectTimeout: p.ingressConnectTimeout(ingress, p.ConnectTimeout, log)},
+			MaxRequestsPerConnection:      p.MaxRequestsPerConnection,
+			PerConnectionBufferLimitBytes: p.PerConnectionBufferLimitBytes,
+			UpstreamTLS:                   p.UpstreamTLS,
+			CircuitBreakers:               p.ingressCircuitBreakers(ingress, log),
+		}},
+		RequestHeadersPolicy:  p.RequestHeadersPolicy,
+		ResponseHeadersPolicy: p.ResponseHeadersPolicy,
+	}
+
+	if p.SetSourceMetadataOnRoutes {
+		r.Kind = "Ingress"
+		r.Namespace = ingress.Namespace
+		r.Name = ingress.Name
+	}
+
+	r.RateLimitPolicy = p.ingressRateLimitPolicy(ingress, log)
+
+	return r, nil
+}
+
+// ingressRateLimitPolicy builds the RateLimitPolicy for an Ingress backend.
+// The global policy is the operator-wide GlobalRateLimitService default,
+// overridden by any per-Ingress global rate-limit annotations; the local
+// policy comes entirely from the per-Ingress local rate-limit annotations.
+// Global and local rate limiting are independent and may both be set on
+// the same Route.
+func (p *IngressProcessor) ingressRateLimitPolicy(ingress *networking_v1.Ingress, log logrus.FieldLogger) *RateLimitPolicy {
+	global := p.defaultGlobalRateLimitPolicy()
+
+	if annotated := p.ingressGlobalRateLimitAnnotations(ingress, log); annotated != nil {
+		global = annotated
+	}
+
+	local := p.ingressLocalRateLimitAnnotations(ingress, log)
+
+	if global == nil && local == nil {
+		return nil
+	}
+
+	return &RateLimitPolicy{
+		Global: global,
+		Local:  local,
+	}
+}
+
+// defaultGlobalRateLimitPolicy copies the operator-wide default global rate
+// limit policy, if one is configured, into the DAG representation.
+func (p *IngressProcessor) defaultGlobalRateLimitPolicy() *GlobalRateLimitPolicy {
+	if p.GlobalRateLimitService == nil || p.GlobalRateLimitService.DefaultGlobalRateLimitPolicy == nil {
+		return nil
+	}
+	return globalRateLimitPolicy(p.GlobalRateLimitService.DefaultGlobalRateLimitPolicy)
+}
+
+// ingressGlobalRateLimitAnnotations parses the
+// projectcontour.io/global-rate-limit-requests-per-unit,
+// projectcontour.io/global-rate-limit-unit and
+// projectcontour.io/global-rate-limit-descriptor-key annotations on ing,
+// returning a single-descriptor GlobalRateLimitPolicy synthesized from them,
+// or nil if the requests-per-unit annotation is not set.
+func (p *IngressProcessor) ingressGlobalRateLimitAnnotations(ing *networking_v1.Ingress, log logrus.FieldLogger) *GlobalRateLimitPolicy {
+	rpu, ok := ing.Annotations[annotationGlobalRateLimitRequestsPerUnit]
+	if !ok {
+		return nil
+	}
+
+	requests, err := strconv.ParseUint(rpu, 10, 32)
+	if err != nil {
+		log.WithError(err).Errorf("error parsing %q annotation", annotationGlobalRateLimitRequestsPerUnit)
+		return nil
+	}
+
+	unit := validateRateLimitUnit(ing.Annotations[annotationGlobalRateLimitUnit], annotationGlobalRateLimitUnit, log)
+
+	entry, err := rateLimitDescriptorEntry(ing.Annotations[annotationGlobalRateLimitDescriptorKey])
+	if err != nil {
+		log.WithError(err).Errorf("error parsing %q annotation", annotationGlobalRateLimitDescriptorKey)
+		return nil
+	}
+
+	return &GlobalRateLimitPolicy{
+		Descriptors: []*RateLimitDescriptor{
+			{
+				Entries: []RateLimitDescriptorEntry{entry},
+			},
+		},
+		Requests: uint32(requests),
+		Unit:     unit,
+	}
+}
+
+// ingressCircuitBreakers builds the per-cluster CircuitBreakers for an
+// Ingress backend from the max-connections, max-pending-requests,
+// max-requests and max-retries annotations on ing, falling back to
+// GlobalCircuitBreakerDefaults for any value that isn't annotated. Returns
+// nil if neither the annotations nor GlobalCircuitBreakerDefaults are set.
+func (p *IngressProcessor) ingressCircuitBreakers(ing *networking_v1.Ingress, log logrus.FieldLogger) *contour_v1alpha1.CircuitBreakers {
+	var cb contour_v1alpha1.CircuitBreakers
+	if p.GlobalCircuitBreakerDefaults != nil {
+		cb = *p.GlobalCircuitBreakerDefaults
+	}
+
+	set := false
+	if v, ok := parseUint32Annotation(ing, annotationMaxConnections, log); ok {
+		cb.MaxConnections = v
+		set = true
+	}
+	if v, ok := parseUint32Annotation(ing, annotationMaxPendingRequests, log); ok {
+		cb.MaxPendingRequests = v
+		set = true
+	}
+	if v, ok := parseUint32Annotation(ing, annotationMaxRequests, log); ok {
+		cb.MaxRequests = v
+		set = true
+	}
+	if v, ok := parseUint32Annotation(ing, annotationMaxRetries, log); ok {
+		cb.MaxRetries = v
+		set = true
+	}
+
+	if !set && p.GlobalCircuitBreakerDefaults == nil {
+		return nil
+	}
+	return &cb
+}
+
+// parseUint32Annotation returns the value of the named annotation on ing
+// parsed as a uint32, and whether the annotation was present and valid.
+func parseUint32Annotation(ing *networking_v1.Ingress, name string, log logrus.FieldLogger) (uint32, bool) {
+	val, ok := ing.Annotations[name]
+	if !ok {
+		return 0, false
+	}
+
+	n, err := strconv.ParseUint(val, 10, 32)
+	if err != nil {
+		log.WithError(err).Errorf("error parsing %q annotation", name)
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// ingressLocalRateLimitAnnotations parses the
+// projectcontour.io/local-rate-limit-requests,
+// projectcontour.io/local-rate-limit-unit,
+// projectcontour.io/local-rate-limit-burst and
+// projectcontour.io/local-rate-limit-response-status-code annotations on
+// ing into a LocalRateLimitPolicy enforced in-proxy (no external Rate Limit
+// Service is required), returning nil if the requests annotation is not
+// set.
+func (p *IngressProcessor) ingressLocalRateLimitAnnotations(ing *networking_v1.Ingress, log logrus.FieldLogger) *LocalRateLimitPolicy {
+	reqs, ok := ing.Annotations[annotationLocalRateLimitRequests]
+	if !ok {
+		return nil
+	}
+
+	requests, err := strconv.ParseUint(reqs, 10, 32)
+	if err != nil {
+		log.WithError(err).Errorf("error parsing %q annotation", annotationLocalRateLimitRequests)
+		return nil
+	}
+
+	unit := validateRateLimitUnit(ing.Annotations[annotationLocalRateLimitUnit], annotationLocalRateLimitUnit, log)
+
+	policy := &LocalRateLimitPolicy{
+		MaxTokens:     uint32(requests),
+		TokensPerFill: uint32(requests),
+		FillInterval:  rateLimitUnitToDuration(unit),
+	}
+
+	if v, ok := parseUint32Annotation(ing, annotationLocalRateLimitBurst, log); ok {
+		policy.MaxTokens += v
+	}
+
+	if v, ok := parseHTTPStatusCodeAnnotation(ing, annotationLocalRateLimitResponseStatusCode, log); ok {
+		policy.ResponseStatusCode = v
+	}
+
+	return policy
+}
+
+// parseHTTPStatusCodeAnnotation returns the value of the named annotation on
+// ing parsed as a uint32, and whether it was present and a valid HTTP
+// status code in the 400-599 range used for rate-limit rejection responses.
+func parseHTTPStatusCodeAnnotation(ing *networking_v1.Ingress, name string, log logrus.FieldLogger) (uint32, bool) {
+	v, ok := parseUint32Annotation(ing, name, log)
+	if !ok {
+		return 0, false
+	}
+
+	if v < 400 || v > 599 {
+		log.Errorf("error parsing %q annotation: %d is not a valid HTTP status code in the 400-599 range", name, v)
+		return 0, false
+	}
+
+	return v, true
+}
+
+// validateRateLimitUnit checks that unit is one of the known rate-limit
+// units ("second", "minute" or "hour"), returning it unchanged if so. An
+// empty value is treated as "second". An unrecognized value is logged and
+// replaced with "second" so that a typo like "fortnight" is rejected at
+// parse time rather than flowing through to an invalid descriptor.
+func validateRateLimitUnit(unit, annotationName string, log logrus.FieldLogger) string {
+	switch unit {
+	case "":
+		return "second"
+	case "second", "minute", "hour":
+		return unit
+	default:
+		log.Errorf("error parsing %q annotation: unsupported unit %q, falling back to \"second\"", annotationName, unit)
+		return "second"
+	}
+}
+
+// rateLimitUnitToDuration converts a unit already validated by
+// validateRateLimitUnit ("second", "minute" or "hour") into the Envoy
+// fill_interval duration it represents, defaulting to time.Second for any
+// other value.
+func rateLimitUnitToDuration(unit string) time.Duration {
+	switch unit {
+	case "minute":
+		return time.Minute
+	case "hour":
+		return time.Hour
+	default:
+		return time.Second
+	}
+}
+
+// rateLimitDescriptorEntry translates the value of the
+// global-rate-limit-descriptor-key annotation into a RateLimitDescriptorEntry.
+// Supported values are "remote_address", "path", and "header:<name>". An
+// empty value defaults to "remote_address".
+func rateLimitDescriptorEntry(key string) (RateLimitDescriptorEntry, error) {
+	switch {
+	case key == "" || key == descriptorKeyRemoteAddress:
+		return RateLimitDescriptorEntry{RemoteAddress: &RemoteAddressDescriptorEntry{}}, nil
+	case key == descriptorKeyPath:
+		return RateLimitDescriptorEntry{RequestHeader: &RequestHeaderDescriptorEntry{HeaderName: ":path", DescriptorKey: descriptorKeyPath}}, nil
+	case strings.HasPrefix(key, descriptorKeyHeaderPrefix):
+		name := strings.TrimPrefix(key, descriptorKeyHeaderPrefix)
+		if name == "" {
+			return RateLimitDescriptorEntry{}, fmt.Errorf("global-rate-limit descriptor key %q must specify a header name", key)
+		}
+		return RateLimitDescriptorEntry{RequestHeader: &RequestHeaderDescriptorEntry{HeaderName: name, DescriptorKey: name}}, nil
+	default:
+		return RateLimitDescriptorEntry{}, fmt.Errorf("unsupported global-rate-limit descriptor key %q", key)
+	}
+}
+
+// globalRateLimitPolicy copies a v1.GlobalRateLimitPolicy (as configured on
+// HTTPProxy, and as the ContourConfiguration's default global rate limit
+// policy) into the equivalent DAG type. Only RemoteAddress and RequestHeader
+// descriptor entries are copied; entries of any other kind are dropped.
+func globalRateLimitPolicy(policy *v1.GlobalRateLimitPolicy) *GlobalRateLimitPolicy {
+	if policy == nil {
+		return nil
+	}
+
+	gp := &GlobalRateLimitPolicy{}
+	for _, d := range policy.Descriptors {
+		rd := &RateLimitDescriptor{}
+		for _, e := range d.Entries {
+			switch {
+			case e.RemoteAddress != nil:
+				rd.Entries = append(rd.Entries, RateLimitDescriptorEntry{RemoteAddress: &RemoteAddressDescriptorEntry{}})
+			case e.RequestHeader != nil:
+				rd.Entries = append(rd.Entries, RateLimitDescriptorEntry{
+					RequestHeader: &RequestHeaderDescriptorEntry{
+						HeaderName:    e.RequestHeader.HeaderName,
+						DescriptorKey: e.RequestHeader.DescriptorKey,
+					},
+				})
+			}
+		}
+		gp.Descriptors = append(gp.Descriptors, rd)
+	}
+	return gp
+}
+
+func stringOrDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -127,29 +127,68 @@ func TestIngressProcessor_ingressTimeoutPolicy(t *testing.T) {
 				ResponseTimeout: timeout.DurationSetting(5 * time.Second),
 			},
 		},
-		// {
-		// 	name: "timeout policy with connect timeout",
-		// 	fields: fields{
-		// 		ResponseTimeout: timeout.DefaultSetting(),
-		// 		ConnectTimeout:  0,
-		// 	},
-		// 	args: args{
-		// 		ingress: &networking_v1.Ingress{
-		// 			ObjectMeta: metav1.ObjectMeta{
-		// 				Annotations: map[string]string{
-		// 					"projectcontour.io/response-timeout": "5s",
-		// 					"projectcontour.io/request-timeout":  "10s",
-		// 					"projectcontour.io/connect-timeout":  "15s",
-		// 				},
-		// 			},
-		// 		},
-		// 		log: logrus.NewEntry(logrus.StandardLogger()),
-		// 	},
-		// 	want: RouteTimeoutPolicy{
-		// 		ResponseTimeout: timeout.DurationSetting(5 * time.Second),
-		// 		IdleTimeout:     timeout.DurationSetting(15 * time.Second),
-		// 	},
-		// },
+		{
+			name: "timeout policy with idle timeout",
+			fields: fields{
+				ResponseTimeout: timeout.DefaultSetting(),
+				ConnectTimeout:  0,
+			},
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/response-timeout": "5s",
+							"projectcontour.io/request-timeout":  "10s",
+							"projectcontour.io/idle-timeout":     "15s",
+						},
+					},
+				},
+				log: logrus.NewEntry(logrus.StandardLogger()),
+			},
+			want: RouteTimeoutPolicy{
+				ResponseTimeout: timeout.DurationSetting(5 * time.Second),
+				IdleTimeout:     timeout.DurationSetting(15 * time.Second),
+			},
+		},
+		{
+			name: "timeout policy with idle connection timeout",
+			fields: fields{
+				ResponseTimeout: timeout.DefaultSetting(),
+			},
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/idle-connection-timeout": "infinity",
+						},
+					},
+				},
+				log: logrus.NewEntry(logrus.StandardLogger()),
+			},
+			want: RouteTimeoutPolicy{
+				ResponseTimeout:       timeout.DefaultSetting(),
+				IdleConnectionTimeout: timeout.DisabledSetting(),
+			},
+		},
+		{
+			name: "invalid idle-timeout annotation is ignored",
+			fields: fields{
+				ResponseTimeout: timeout.DefaultSetting(),
+			},
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/idle-timeout": "not-a-duration",
+						},
+					},
+				},
+				log: logrus.NewEntry(logrus.StandardLogger()),
+			},
+			want: RouteTimeoutPolicy{
+				ResponseTimeout: timeout.DefaultSetting(),
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -265,6 +304,386 @@ func TestIngressProcessor_route_ratelimiting(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "global-rate-limit annotations, default descriptor key",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/global-rate-limit-requests-per-unit": "10",
+							"projectcontour.io/global-rate-limit-unit":              "minute",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "global-rate-limit annotations, default descriptor key"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RemoteAddress: &RemoteAddressDescriptorEntry{},
+									},
+								},
+							},
+						},
+						Requests: 10,
+						Unit:     "minute",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "global-rate-limit annotations, header descriptor key",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/global-rate-limit-requests-per-unit": "5",
+							"projectcontour.io/global-rate-limit-unit":              "second",
+							"projectcontour.io/global-rate-limit-descriptor-key":    "header:x-api-key",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "global-rate-limit annotations, header descriptor key"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RequestHeader: &RequestHeaderDescriptorEntry{
+											HeaderName:    "x-api-key",
+											DescriptorKey: "x-api-key",
+										},
+									},
+								},
+							},
+						},
+						Requests: 5,
+						Unit:     "second",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "global-rate-limit annotations override GlobalRateLimitService default",
+			fields: fields{
+				GlobalRateLimitService: &contour_v1alpha1.RateLimitServiceConfig{
+					DefaultGlobalRateLimitPolicy: &v1.GlobalRateLimitPolicy{
+						Descriptors: []v1.RateLimitDescriptor{
+							{
+								Entries: []v1.RateLimitDescriptorEntry{
+									{
+										RemoteAddress: &v1.RemoteAddressDescriptor{},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/global-rate-limit-requests-per-unit": "100",
+							"projectcontour.io/global-rate-limit-unit":              "hour",
+							"projectcontour.io/global-rate-limit-descriptor-key":    "path",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "global-rate-limit annotations override GlobalRateLimitService default"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RequestHeader: &RequestHeaderDescriptorEntry{
+											HeaderName:    ":path",
+											DescriptorKey: "path",
+										},
+									},
+								},
+							},
+						},
+						Requests: 100,
+						Unit:     "hour",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "GlobalRateLimitService default with a request-header descriptor is preserved",
+			fields: fields{
+				GlobalRateLimitService: &contour_v1alpha1.RateLimitServiceConfig{
+					DefaultGlobalRateLimitPolicy: &v1.GlobalRateLimitPolicy{
+						Descriptors: []v1.RateLimitDescriptor{
+							{
+								Entries: []v1.RateLimitDescriptorEntry{
+									{
+										RequestHeader: &v1.RequestHeaderDescriptor{
+											HeaderName:    "x-api-key",
+											DescriptorKey: "x-api-key",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			args: args{
+				ingress: &networking_v1.Ingress{},
+				host:    "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "GlobalRateLimitService default with a request-header descriptor is preserved"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RequestHeader: &RequestHeaderDescriptorEntry{
+											HeaderName:    "x-api-key",
+											DescriptorKey: "x-api-key",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid global-rate-limit-unit falls back to second",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/global-rate-limit-requests-per-unit": "10",
+							"projectcontour.io/global-rate-limit-unit":              "fortnight",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "invalid global-rate-limit-unit falls back to second"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RemoteAddress: &RemoteAddressDescriptorEntry{},
+									},
+								},
+							},
+						},
+						Requests: 10,
+						Unit:     "second",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "local-rate-limit annotations only",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/local-rate-limit-requests":             "20",
+							"projectcontour.io/local-rate-limit-unit":                 "minute",
+							"projectcontour.io/local-rate-limit-burst":                "5",
+							"projectcontour.io/local-rate-limit-response-status-code": "429",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "local-rate-limit annotations only"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Local: &LocalRateLimitPolicy{
+						MaxTokens:          25,
+						TokensPerFill:      20,
+						FillInterval:       time.Minute,
+						ResponseStatusCode: 429,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "out-of-range local-rate-limit-response-status-code is ignored",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/local-rate-limit-requests":             "20",
+							"projectcontour.io/local-rate-limit-response-status-code": "99999",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "out-of-range local-rate-limit-response-status-code is ignored"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Local: &LocalRateLimitPolicy{
+						MaxTokens:     20,
+						TokensPerFill: 20,
+						FillInterval:  time.Second,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid local-rate-limit-unit falls back to second, rest of policy kept",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/local-rate-limit-requests": "20",
+							"projectcontour.io/local-rate-limit-unit":     "seconds",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "invalid local-rate-limit-unit falls back to second, rest of policy kept"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Local: &LocalRateLimitPolicy{
+						MaxTokens:     20,
+						TokensPerFill: 20,
+						FillInterval:  time.Second,
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "global-rate-limit annotations only, no local policy",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/global-rate-limit-requests-per-unit": "10",
+							"projectcontour.io/global-rate-limit-unit":              "second",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "global-rate-limit annotations only, no local policy"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RemoteAddress: &RemoteAddressDescriptorEntry{},
+									},
+								},
+							},
+						},
+						Requests: 10,
+						Unit:     "second",
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "both global and local rate-limit annotations configured",
+			args: args{
+				ingress: &networking_v1.Ingress{
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"projectcontour.io/global-rate-limit-requests-per-unit": "10",
+							"projectcontour.io/global-rate-limit-unit":              "second",
+							"projectcontour.io/local-rate-limit-requests":           "100",
+						},
+					},
+				},
+				host: "test",
+				service: &Service{
+					Protocol: "http",
+				},
+				log: logrus.New().WithField("test", "both global and local rate-limit annotations configured"),
+			},
+			want: &Route{
+				RateLimitPolicy: &RateLimitPolicy{
+					Global: &GlobalRateLimitPolicy{
+						Descriptors: []*RateLimitDescriptor{
+							{
+								Entries: []RateLimitDescriptorEntry{
+									{
+										RemoteAddress: &RemoteAddressDescriptorEntry{},
+									},
+								},
+							},
+						},
+						Requests: 10,
+						Unit:     "second",
+					},
+					Local: &LocalRateLimitPolicy{
+						MaxTokens:     100,
+						TokensPerFill: 100,
+						FillInterval:  time.Second,
+					},
+				},
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -296,3 +715,127 @@ func TestIngressProcessor_route_ratelimiting(t *testing.T) {
 		})
 	}
 }
+
+func TestIngressProcessor_ingressConnectTimeout(t *testing.T) {
+	tests := map[string]struct {
+		annotations map[string]string
+		def         time.Duration
+		want        time.Duration
+	}{
+		"no annotation uses default": {
+			def:  5 * time.Second,
+			want: 5 * time.Second,
+		},
+		"valid annotation overrides default": {
+			annotations: map[string]string{
+				"projectcontour.io/connect-timeout": "2s",
+			},
+			def:  5 * time.Second,
+			want: 2 * time.Second,
+		},
+		"invalid duration falls back to default": {
+			annotations: map[string]string{
+				"projectcontour.io/connect-timeout": "not-a-duration",
+			},
+			def:  5 * time.Second,
+			want: 5 * time.Second,
+		},
+		"infinity is not a valid connect-timeout and falls back to default": {
+			annotations: map[string]string{
+				"projectcontour.io/connect-timeout": "infinity",
+			},
+			def:  5 * time.Second,
+			want: 5 * time.Second,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &IngressProcessor{}
+			ing := &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			got := p.ingressConnectTimeout(ing, tc.def, logrus.New().WithField("test", name))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestIngressProcessor_circuitBreakers(t *testing.T) {
+	tests := map[string]struct {
+		globalDefaults *contour_v1alpha1.CircuitBreakers
+		annotations    map[string]string
+		want           *contour_v1alpha1.CircuitBreakers
+	}{
+		"no annotations, no global defaults": {
+			want: nil,
+		},
+		"annotations only": {
+			annotations: map[string]string{
+				"projectcontour.io/max-connections":      "100",
+				"projectcontour.io/max-pending-requests": "200",
+				"projectcontour.io/max-requests":         "300",
+				"projectcontour.io/max-retries":          "4",
+			},
+			want: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections:     100,
+				MaxPendingRequests: 200,
+				MaxRequests:        300,
+				MaxRetries:         4,
+			},
+		},
+		"per-service override of a subset of the global defaults": {
+			globalDefaults: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections:     1000,
+				MaxPendingRequests: 1000,
+				MaxRequests:        1000,
+				MaxRetries:         3,
+			},
+			annotations: map[string]string{
+				"projectcontour.io/max-requests": "50",
+			},
+			want: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections:     1000,
+				MaxPendingRequests: 1000,
+				MaxRequests:        50,
+				MaxRetries:         3,
+			},
+		},
+		"falls back to global defaults when no annotations set": {
+			globalDefaults: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections: 500,
+			},
+			want: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections: 500,
+			},
+		},
+		"invalid annotation value is ignored": {
+			globalDefaults: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections: 500,
+			},
+			annotations: map[string]string{
+				"projectcontour.io/max-connections": "not-a-number",
+			},
+			want: &contour_v1alpha1.CircuitBreakers{
+				MaxConnections: 500,
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &IngressProcessor{
+				GlobalCircuitBreakerDefaults: tc.globalDefaults,
+			}
+			ing := &networking_v1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: tc.annotations,
+				},
+			}
+			got := p.ingressCircuitBreakers(ing, logrus.New().WithField("test", name))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}